@@ -0,0 +1,127 @@
+//go:build linux
+
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+// benchGoroutinePerConn mirrors the old handleConn model: one goroutine and
+// one bufSize (4 KiB) buffer per connection, looping on blocking
+// reads/writes.
+func benchGoroutinePerConn(b *testing.B, conns int) {
+	ln, client, server := dialPairs(b, conns)
+	defer ln.Close()
+
+	for _, c := range server {
+		go func(c net.Conn) {
+			buf := make([]byte, bufSize)
+			for {
+				n, err := c.Read(buf)
+				if err != nil {
+					return
+				}
+				if n > 0 {
+					c.Write(rawResponse)
+				}
+			}
+		}(c)
+	}
+
+	msg := []byte("ping")
+	reply := make([]byte, len(rawResponse))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := client[i%len(client)]
+		c.Write(msg)
+		c.Read(reply)
+	}
+}
+
+// benchEngine drives the same workload through Engine.Read/Write instead of
+// a goroutine blocking per connection.
+func benchEngine(b *testing.B, conns int) {
+	e, err := NewEngine()
+	if err != nil {
+		b.Fatal(err)
+	}
+	ln, client, server := dialPairs(b, conns)
+	defer ln.Close()
+
+	fds := make([]int, len(server))
+	for i, c := range server {
+		fd, err := e.Register(c)
+		if err != nil {
+			b.Fatal(err)
+		}
+		fds[i] = fd
+	}
+
+	var serve func(i int)
+	serve = func(i int) {
+		e.Read(fds[i], nil, func(buf []byte, n int, err error) {
+			if err != nil || n == 0 {
+				return
+			}
+			e.Write(fds[i], rawResponse, func(_ []byte, _ int, _ error) {
+				serve(i)
+			})
+		})
+	}
+	for i := range server {
+		serve(i)
+	}
+
+	msg := []byte("ping")
+	reply := make([]byte, len(rawResponse))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := client[i%len(client)]
+		c.Write(msg)
+		c.Read(reply)
+	}
+}
+
+// dialPairs opens conns loopback TCP connections and returns the client and
+// accepted-server ends alongside the listener. tb is testing.TB so both
+// benchmarks and tests can share it.
+func dialPairs(tb testing.TB, conns int) (net.Listener, []net.Conn, []net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	server := make([]net.Conn, 0, conns)
+	accepted := make(chan net.Conn, conns)
+	go func() {
+		for i := 0; i < conns; i++ {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	client := make([]net.Conn, 0, conns)
+	for i := 0; i < conns; i++ {
+		c, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			tb.Fatal(err)
+		}
+		client = append(client, c)
+		server = append(server, <-accepted)
+	}
+	return ln, client, server
+}
+
+func BenchmarkGoroutinePerConn_1k(b *testing.B)   { benchGoroutinePerConn(b, 1000) }
+func BenchmarkGoroutinePerConn_10k(b *testing.B)  { benchGoroutinePerConn(b, 10000) }
+func BenchmarkGoroutinePerConn_100k(b *testing.B) { benchGoroutinePerConn(b, 100000) }
+
+func BenchmarkEngine_1k(b *testing.B)   { benchEngine(b, 1000) }
+func BenchmarkEngine_10k(b *testing.B)  { benchEngine(b, 10000) }
+func BenchmarkEngine_100k(b *testing.B) { benchEngine(b, 100000) }
@@ -0,0 +1,426 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"syscall"
+)
+
+// epollET is EPOLLET's bit as a uint32; syscall.EPOLLET is typed int and
+// carries the sign bit, so it can't be OR'd directly into an EpollEvent's
+// uint32 Events field.
+const epollET = 0x80000000
+
+// swapPool lends scratch buffers to callbacks that pass a nil buffer to
+// Engine.Read, so the engine never allocates a fresh 4 KiB slice per
+// connection.
+var swapPool = sync.Pool{
+	New: func() interface{} { return make([]byte, bufSize) },
+}
+
+// Callback is invoked when a read or write an Engine was asked to perform
+// completes. buf is only valid for the duration of the call; if the engine
+// lent it from the swap pool it is returned to the pool as soon as Callback
+// returns.
+type Callback func(buf []byte, n int, err error)
+
+// conask describes one outstanding read or write that couldn't complete
+// immediately and is now waiting on its fd's next readiness edge.
+type conask struct {
+	buf    []byte
+	pooled bool
+	cb     Callback
+}
+
+// fdState tracks one registered connection's queued asks and which epoll
+// interest bits are currently armed for it. Interest is armed lazily —
+// only once an ask actually hits EAGAIN — and disarmed again as soon as
+// its queue drains. Arming on demand like this means a readiness edge is
+// never watched (and, being edge-triggered, silently missed forever)
+// before anything is actually waiting on it.
+type fdState struct {
+	mu       sync.Mutex
+	epfd     int
+	fd       int
+	interest uint32
+	reads    []conask
+	writes   []conask
+}
+
+// ioRingSize bounds how many completed-readiness notifications can sit in
+// a loop's dispatch ring before its epoll-wait goroutine spins waiting for
+// room. Must be a power of two (see NewRing).
+const ioRingSize = 4096
+
+// ioWorkersPerLoop is the number of goroutines draining each loop's
+// dispatch ring and actually performing the retried reads/writes.
+const ioWorkersPerLoop = 4
+
+// ioDir says which of an fdState's queues an ioTask should drain.
+type ioDir uint8
+
+const (
+	ioRead ioDir = iota
+	ioWrite
+)
+
+// ioTask is one unit of dispatch work for a loop's worker pool: either fd
+// fs just became ready for dir and whichever asks are queued on that side
+// should be retried, or run is a completion to invoke directly. Routing
+// completions through here too, instead of calling a Callback inline,
+// keeps Read/Write from recursing straight back into the engine on the
+// same goroutine stack when a caller's completion callback immediately
+// issues another Read/Write that completes without hitting EAGAIN.
+type ioTask struct {
+	fs  *fdState
+	dir ioDir
+	run func()
+}
+
+// ioQueue pairs a loop's ioTask dispatch Ring with a condition variable so
+// idle workers block instead of busy-spinning when it's empty, and wake as
+// soon as the loop's epoll_wait goroutine pushes the next ready fd.
+type ioQueue struct {
+	ring *Ring[*ioTask]
+	mu   sync.Mutex
+	cond *sync.Cond
+}
+
+func newIOQueue(size uint32) *ioQueue {
+	q := &ioQueue{ring: NewRing[*ioTask](size)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push publishes t, spinning only in the rare case the ring is momentarily
+// full, then wakes a worker blocked in pop.
+func (q *ioQueue) push(t *ioTask) {
+	for !q.ring.TryPush(t) {
+		runtime.Gosched()
+	}
+	q.cond.Broadcast()
+}
+
+// pop returns the next ioTask, blocking until one is available. The
+// lock-free TryPop is tried first so the common, non-empty case never pays
+// for the mutex; the cond/mutex pair is only touched once the ring looks
+// empty.
+func (q *ioQueue) pop() *ioTask {
+	if t, ok := q.ring.TryPop(); ok {
+		return t
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if t, ok := q.ring.TryPop(); ok {
+			return t
+		}
+		q.cond.Wait()
+	}
+}
+
+// loop is one edge-triggered epoll instance, pinned to a dedicated
+// epoll_wait goroutine. Engine runs one loop per CPU so registered
+// connections fan out across them instead of spawning a goroutine each.
+// The epoll_wait goroutine itself does no I/O: it pushes an ioTask onto
+// queue for every fd that became ready, and a small pool of worker
+// goroutines pops from queue and performs the actual retried read/write.
+// This keeps epoll_wait free to keep polling instead of blocking on
+// syscalls, and queue - not a per-loop mutex - is what's on the hot path
+// between readiness and work.
+type loop struct {
+	epfd  int
+	queue *ioQueue
+	mu    sync.Mutex
+	fds   map[int]*fdState
+}
+
+// Engine is a proactor-style async I/O subsystem built directly on epoll.
+// It replaces the goroutine-per-connection model: connections are
+// registered once, and reads/writes are requested via Read/Write and
+// completed by a small pool of worker loops instead of a dedicated
+// goroutine blocking on conn.Read.
+type Engine struct {
+	loops []*loop
+}
+
+// NewEngine starts one epoll loop per CPU and returns an Engine ready to
+// accept registrations.
+func NewEngine() (*Engine, error) {
+	n := runtime.NumCPU()
+	e := &Engine{loops: make([]*loop, n)}
+	for i := 0; i < n; i++ {
+		epfd, err := syscall.EpollCreate1(0)
+		if err != nil {
+			return nil, fmt.Errorf("epoll_create1: %w", err)
+		}
+		l := &loop{epfd: epfd, queue: newIOQueue(ioRingSize), fds: make(map[int]*fdState)}
+		e.loops[i] = l
+		go l.run()
+		for j := 0; j < ioWorkersPerLoop; j++ {
+			go l.work()
+		}
+	}
+	return e, nil
+}
+
+// loopFor picks the loop a connection's fd is sharded to. Hashing on the fd
+// keeps a connection pinned to the same loop for its whole lifetime.
+func (e *Engine) loopFor(fd int) *loop {
+	return e.loops[fd%len(e.loops)]
+}
+
+// Register adds conn to the engine's epoll set with no interest bits armed
+// yet; Read/Write arm EPOLLIN/EPOLLOUT lazily the first time an operation
+// on this fd actually needs to wait. conn must support SyscallConn (true
+// for *net.TCPConn).
+func (e *Engine) Register(conn net.Conn) (int, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return 0, fmt.Errorf("engine: %T does not support SyscallConn", conn)
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var fd int
+	var ctlErr error
+	err = raw.Control(func(s uintptr) {
+		fd = int(s)
+		l := e.loopFor(fd)
+		ev := syscall.EpollEvent{Fd: int32(fd)}
+		if ctlErr = syscall.EpollCtl(l.epfd, syscall.EPOLL_CTL_ADD, fd, &ev); ctlErr != nil {
+			return
+		}
+		l.mu.Lock()
+		l.fds[fd] = &fdState{epfd: l.epfd, fd: fd}
+		l.mu.Unlock()
+	})
+	if err != nil {
+		return 0, err
+	}
+	if ctlErr != nil {
+		return 0, fmt.Errorf("epoll_ctl add: %w", ctlErr)
+	}
+	return fd, nil
+}
+
+// Unregister removes fd from the engine's epoll set and drops its fdState.
+// Callers must call this once, on the connection's actual close, or its
+// fdState and epoll registration leak for the life of the process.
+func (e *Engine) Unregister(fd int) {
+	l := e.loopFor(fd)
+	l.mu.Lock()
+	delete(l.fds, fd)
+	l.mu.Unlock()
+	syscall.EpollCtl(l.epfd, syscall.EPOLL_CTL_DEL, fd, nil)
+}
+
+// Read asks the engine to perform a read on fd. If buf is nil, a buffer is
+// lent from the swap pool for the duration of cb and released as soon as
+// cb returns. The read is attempted immediately; it only waits for fd's
+// next EPOLLIN edge if that attempt hits EAGAIN. Either way cb runs on a
+// loop worker via the dispatch queue, never inline on the caller's
+// goroutine, so a cb that turns around and calls Read/Write again can't
+// build up unbounded recursion for a connection with data continuously
+// ready.
+func (e *Engine) Read(fd int, buf []byte, cb Callback) {
+	pooled := buf == nil
+	if pooled {
+		buf = swapPool.Get().([]byte)
+	}
+
+	l := e.loopFor(fd)
+	n, err := syscall.Read(fd, buf)
+	if err != syscall.EAGAIN {
+		l.queue.push(&ioTask{run: func() {
+			cb(buf, n, err)
+			if pooled {
+				swapPool.Put(buf[:cap(buf)])
+			}
+		}})
+		return
+	}
+
+	fs := e.state(fd)
+	fs.mu.Lock()
+	fs.reads = append(fs.reads, conask{buf: buf, pooled: pooled, cb: cb})
+	fs.arm(uint32(syscall.EPOLLIN))
+	fs.mu.Unlock()
+}
+
+// Write asks the engine to perform a write of buf on fd, attempting it
+// immediately and only waiting for fd's next EPOLLOUT edge if that attempt
+// hits EAGAIN. As with Read, cb always runs via the dispatch queue rather
+// than inline, so it can't recurse unboundedly into another Read/Write on
+// the calling goroutine's stack.
+func (e *Engine) Write(fd int, buf []byte, cb Callback) {
+	l := e.loopFor(fd)
+	n, err := syscall.Write(fd, buf)
+	if err != syscall.EAGAIN {
+		l.queue.push(&ioTask{run: func() { cb(buf, n, err) }})
+		return
+	}
+
+	fs := e.state(fd)
+	fs.mu.Lock()
+	fs.writes = append(fs.writes, conask{buf: buf, cb: cb})
+	fs.arm(uint32(syscall.EPOLLOUT))
+	fs.mu.Unlock()
+}
+
+func (e *Engine) state(fd int) *fdState {
+	l := e.loopFor(fd)
+	l.mu.Lock()
+	fs := l.fds[fd]
+	l.mu.Unlock()
+	return fs
+}
+
+// arm adds bit to fd's armed interest set and pushes it to the kernel if
+// it wasn't already armed. Callers hold fs.mu.
+func (fs *fdState) arm(bit uint32) {
+	if fs.interest&bit != 0 {
+		return
+	}
+	fs.interest |= bit
+	fs.sync()
+}
+
+// disarm removes bit from fd's armed interest set once its queue drains,
+// so a future readiness edge on the other direction doesn't re-trigger
+// work that's already done. Callers hold fs.mu.
+func (fs *fdState) disarm(bit uint32) {
+	if fs.interest&bit == 0 {
+		return
+	}
+	fs.interest &^= bit
+	fs.sync()
+}
+
+// sync pushes fs's current interest bits to the kernel via EPOLL_CTL_MOD.
+// Callers hold fs.mu.
+func (fs *fdState) sync() {
+	events := fs.interest
+	if events != 0 {
+		events |= epollET
+	}
+	ev := syscall.EpollEvent{Events: events, Fd: int32(fs.fd)}
+	syscall.EpollCtl(fs.epfd, syscall.EPOLL_CTL_MOD, fs.fd, &ev)
+}
+
+// run is the body of one loop's dedicated goroutine: it blocks in
+// epoll_wait and, for each ready fd, pushes an ioTask for whichever of its
+// queues just became ready so a worker retries them.
+func (l *loop) run() {
+	events := make([]syscall.EpollEvent, 256)
+	for {
+		n, err := syscall.EpollWait(l.epfd, events, -1)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			return
+		}
+		for i := 0; i < n; i++ {
+			fd := int(events[i].Fd)
+			l.mu.Lock()
+			fs := l.fds[fd]
+			l.mu.Unlock()
+			if fs == nil {
+				continue
+			}
+			if events[i].Events&syscall.EPOLLIN != 0 {
+				l.queue.push(&ioTask{fs: fs, dir: ioRead})
+			}
+			if events[i].Events&syscall.EPOLLOUT != 0 {
+				l.queue.push(&ioTask{fs: fs, dir: ioWrite})
+			}
+		}
+	}
+}
+
+// work pops ready ioTasks off the loop's queue and runs them, blocking
+// instead of spinning whenever the queue is empty. Several of these run
+// per loop so a single dispatch queue, not the epoll_wait goroutine, is
+// what's on the hot path. Each pop is followed by a non-blocking PopN to
+// drain any further tasks that piled up while this worker was busy,
+// letting a worker pull several ready fds per wake instead of one at a
+// time.
+func (l *loop) work() {
+	batch := make([]*ioTask, 32)
+	for {
+		batch[0] = l.queue.pop()
+		n := 1 + l.queue.ring.PopN(batch[1:])
+		for _, t := range batch[:n] {
+			switch {
+			case t.run != nil:
+				t.run()
+			case t.dir == ioRead:
+				t.fs.drainReads()
+			default:
+				t.fs.drainWrites()
+			}
+		}
+	}
+}
+
+// drainReads retries every queued read now that fd's EPOLLIN edge fired,
+// completing whichever ones no longer hit EAGAIN and disarming EPOLLIN
+// once none are left.
+func (fs *fdState) drainReads() {
+	fs.mu.Lock()
+	reads := fs.reads
+	fs.reads = nil
+	fs.mu.Unlock()
+
+	var again []conask
+	for _, a := range reads {
+		n, err := syscall.Read(fs.fd, a.buf)
+		if err == syscall.EAGAIN {
+			again = append(again, a)
+			continue
+		}
+		a.cb(a.buf, n, err)
+		if a.pooled {
+			swapPool.Put(a.buf[:cap(a.buf)])
+		}
+	}
+
+	fs.mu.Lock()
+	fs.reads = append(again, fs.reads...)
+	if len(fs.reads) == 0 {
+		fs.disarm(uint32(syscall.EPOLLIN))
+	}
+	fs.mu.Unlock()
+}
+
+// drainWrites is drainReads' write-side counterpart.
+func (fs *fdState) drainWrites() {
+	fs.mu.Lock()
+	writes := fs.writes
+	fs.writes = nil
+	fs.mu.Unlock()
+
+	var again []conask
+	for _, a := range writes {
+		n, err := syscall.Write(fs.fd, a.buf)
+		if err == syscall.EAGAIN {
+			again = append(again, a)
+			continue
+		}
+		a.cb(a.buf, n, err)
+	}
+
+	fs.mu.Lock()
+	fs.writes = append(again, fs.writes...)
+	if len(fs.writes) == 0 {
+		fs.disarm(uint32(syscall.EPOLLOUT))
+	}
+	fs.mu.Unlock()
+}
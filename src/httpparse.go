@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+)
+
+// errNeedMore signals that buf does not yet hold a complete request; the
+// caller should read more data and retry parseRequest with the same ctx.
+var errNeedMore = errors.New("http: need more data")
+
+// errMalformed signals a request that cannot be parsed as HTTP/1.1; callers
+// treat it as fatal for the connection.
+var errMalformed = errors.New("http: malformed request")
+
+type header struct {
+	key, value []byte
+}
+
+// RequestCtx is a parsed HTTP/1.1 request. Method, Path, and the byte
+// slices returned by Header alias the connection's read buffer rather than
+// copying it, so they are only valid until the next call to parseRequest
+// reuses the same ctx.
+type RequestCtx struct {
+	method    []byte
+	path      []byte
+	proto     []byte
+	headers   []header
+	body      []byte
+	keepAlive bool
+}
+
+func (ctx *RequestCtx) Method() []byte { return ctx.method }
+func (ctx *RequestCtx) Path() []byte   { return ctx.path }
+func (ctx *RequestCtx) Body() []byte   { return ctx.body }
+
+// Header returns the value of the first header matching name
+// (case-insensitive), or nil if the request has no such header.
+func (ctx *RequestCtx) Header(name string) []byte {
+	for _, h := range ctx.headers {
+		if asciiEqualFold(h.key, name) {
+			return h.value
+		}
+	}
+	return nil
+}
+
+// Handler processes one parsed request and returns the raw bytes to write
+// back to the connection.
+type Handler func(ctx *RequestCtx) []byte
+
+// parseRequest parses one HTTP/1.1 request (request line, headers, and its
+// framed body) from the front of buf in place, without copying. It returns
+// the number of bytes consumed so the caller can keep parsing pipelined
+// requests out of the same buffer. A nil error with ctx.keepAlive set
+// tells the caller whether to expect another request on this connection.
+func parseRequest(buf []byte, ctx *RequestCtx) (int, error) {
+	lineEnd := bytes.Index(buf, []byte("\r\n"))
+	if lineEnd < 0 {
+		return 0, errNeedMore
+	}
+	line := buf[:lineEnd]
+
+	sp1 := bytes.IndexByte(line, ' ')
+	if sp1 < 0 {
+		return 0, errMalformed
+	}
+	rest := line[sp1+1:]
+	sp2 := bytes.IndexByte(rest, ' ')
+	if sp2 < 0 {
+		return 0, errMalformed
+	}
+
+	ctx.method = line[:sp1]
+	ctx.path = rest[:sp2]
+	ctx.proto = rest[sp2+1:]
+	ctx.headers = ctx.headers[:0]
+	ctx.keepAlive = !bytes.Equal(ctx.proto, []byte("HTTP/1.0"))
+
+	off := lineEnd + 2
+	for {
+		hEnd := bytes.Index(buf[off:], []byte("\r\n"))
+		if hEnd < 0 {
+			return 0, errNeedMore
+		}
+		if hEnd == 0 {
+			off += 2
+			break
+		}
+		hLine := buf[off : off+hEnd]
+		colon := bytes.IndexByte(hLine, ':')
+		if colon < 0 {
+			return 0, errMalformed
+		}
+		ctx.headers = append(ctx.headers, header{
+			key:   bytes.TrimSpace(hLine[:colon]),
+			value: bytes.TrimSpace(hLine[colon+1:]),
+		})
+		off += hEnd + 2
+	}
+
+	if v := ctx.Header("Connection"); v != nil {
+		ctx.keepAlive = asciiEqualFold(v, "keep-alive")
+	}
+
+	if v := ctx.Header("Transfer-Encoding"); v != nil && asciiEqualFold(v, "chunked") {
+		body, n, err := parseChunkedBody(buf[off:])
+		if err != nil {
+			return 0, err
+		}
+		ctx.body = body
+		return off + n, nil
+	}
+
+	if v := ctx.Header("Content-Length"); v != nil {
+		n, err := strconv.Atoi(string(v))
+		if err != nil || n < 0 {
+			return 0, errMalformed
+		}
+		if len(buf)-off < n {
+			return 0, errNeedMore
+		}
+		ctx.body = buf[off : off+n]
+		return off + n, nil
+	}
+
+	ctx.body = nil
+	return off, nil
+}
+
+// parseChunkedBody decodes a Transfer-Encoding: chunked body starting at
+// the front of buf, through the terminating 0-length chunk and its
+// trailing CRLF. The decoded data is compacted into the front of buf in
+// place, since it is always shorter than the framing it replaces.
+func parseChunkedBody(buf []byte) ([]byte, int, error) {
+	out := buf[:0:len(buf)]
+	off := 0
+	for {
+		lineEnd := bytes.Index(buf[off:], []byte("\r\n"))
+		if lineEnd < 0 {
+			return nil, 0, errNeedMore
+		}
+		sizeLine := buf[off : off+lineEnd]
+		if semi := bytes.IndexByte(sizeLine, ';'); semi >= 0 {
+			sizeLine = sizeLine[:semi]
+		}
+		size, err := strconv.ParseInt(string(bytes.TrimSpace(sizeLine)), 16, 64)
+		if err != nil || size < 0 {
+			return nil, 0, errMalformed
+		}
+		off += lineEnd + 2
+
+		if size == 0 {
+			if len(buf) < off+2 {
+				return nil, 0, errNeedMore
+			}
+			off += 2
+			return out, off, nil
+		}
+
+		if int64(len(buf)-off) < size+2 {
+			return nil, 0, errNeedMore
+		}
+		out = append(out, buf[off:off+int(size)]...)
+		off += int(size) + 2
+	}
+}
+
+func asciiEqualFold(b []byte, s string) bool {
+	if len(b) != len(s) {
+		return false
+	}
+	for i := 0; i < len(b); i++ {
+		c, d := b[i], s[i]
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		if 'A' <= d && d <= 'Z' {
+			d += 'a' - 'A'
+		}
+		if c != d {
+			return false
+		}
+	}
+	return true
+}
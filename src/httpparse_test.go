@@ -0,0 +1,152 @@
+package main
+
+import "testing"
+
+func TestParseRequestSimple(t *testing.T) {
+	buf := []byte("GET /hello HTTP/1.1\r\nHost: x\r\n\r\n")
+	var ctx RequestCtx
+	n, err := parseRequest(buf, &ctx)
+	if err != nil {
+		t.Fatalf("parseRequest: %v", err)
+	}
+	if n != len(buf) {
+		t.Fatalf("consumed %d, want %d", n, len(buf))
+	}
+	if string(ctx.Method()) != "GET" || string(ctx.Path()) != "/hello" {
+		t.Fatalf("method/path = %q %q", ctx.Method(), ctx.Path())
+	}
+	if !ctx.keepAlive {
+		t.Fatal("HTTP/1.1 with no Connection header should default to keep-alive")
+	}
+	if ctx.Body() != nil {
+		t.Fatalf("body = %q, want nil", ctx.Body())
+	}
+}
+
+func TestParseRequestHTTP10DefaultsToClose(t *testing.T) {
+	buf := []byte("GET / HTTP/1.0\r\n\r\n")
+	var ctx RequestCtx
+	if _, err := parseRequest(buf, &ctx); err != nil {
+		t.Fatalf("parseRequest: %v", err)
+	}
+	if ctx.keepAlive {
+		t.Fatal("HTTP/1.0 with no Connection header should default to close")
+	}
+}
+
+func TestParseRequestConnectionHeaderOverrides(t *testing.T) {
+	buf := []byte("GET / HTTP/1.1\r\nConnection: close\r\n\r\n")
+	var ctx RequestCtx
+	if _, err := parseRequest(buf, &ctx); err != nil {
+		t.Fatalf("parseRequest: %v", err)
+	}
+	if ctx.keepAlive {
+		t.Fatal("Connection: close should override the HTTP/1.1 default")
+	}
+}
+
+func TestParseRequestContentLength(t *testing.T) {
+	buf := []byte("POST /echo HTTP/1.1\r\nContent-Length: 5\r\n\r\nhello")
+	var ctx RequestCtx
+	n, err := parseRequest(buf, &ctx)
+	if err != nil {
+		t.Fatalf("parseRequest: %v", err)
+	}
+	if n != len(buf) {
+		t.Fatalf("consumed %d, want %d", n, len(buf))
+	}
+	if string(ctx.Body()) != "hello" {
+		t.Fatalf("body = %q, want %q", ctx.Body(), "hello")
+	}
+}
+
+func TestParseRequestNeedsMore(t *testing.T) {
+	cases := [][]byte{
+		[]byte("GET / HTTP/1.1\r\n"),
+		[]byte("GET / HTTP/1.1\r\nHost: x\r\n"),
+		[]byte("POST / HTTP/1.1\r\nContent-Length: 5\r\n\r\nhel"),
+	}
+	for _, buf := range cases {
+		var ctx RequestCtx
+		if _, err := parseRequest(buf, &ctx); err != errNeedMore {
+			t.Fatalf("parseRequest(%q) = %v, want errNeedMore", buf, err)
+		}
+	}
+}
+
+func TestParseRequestMalformed(t *testing.T) {
+	cases := [][]byte{
+		[]byte("GET\r\n\r\n"),
+		[]byte("GET /\r\n\r\n"),
+		[]byte("GET / HTTP/1.1\r\nBadHeader\r\n\r\n"),
+	}
+	for _, buf := range cases {
+		var ctx RequestCtx
+		if _, err := parseRequest(buf, &ctx); err != errMalformed {
+			t.Fatalf("parseRequest(%q) = %v, want errMalformed", buf, err)
+		}
+	}
+}
+
+func TestParseRequestPipelined(t *testing.T) {
+	buf := []byte("GET /a HTTP/1.1\r\n\r\nGET /b HTTP/1.1\r\n\r\n")
+	var ctx RequestCtx
+	n1, err := parseRequest(buf, &ctx)
+	if err != nil {
+		t.Fatalf("first parseRequest: %v", err)
+	}
+	if string(ctx.Path()) != "/a" {
+		t.Fatalf("first path = %q, want /a", ctx.Path())
+	}
+	n2, err := parseRequest(buf[n1:], &ctx)
+	if err != nil {
+		t.Fatalf("second parseRequest: %v", err)
+	}
+	if string(ctx.Path()) != "/b" {
+		t.Fatalf("second path = %q, want /b", ctx.Path())
+	}
+	if n1+n2 != len(buf) {
+		t.Fatalf("consumed %d+%d, want %d", n1, n2, len(buf))
+	}
+}
+
+func TestParseChunkedBody(t *testing.T) {
+	buf := []byte("5\r\nhello\r\n6\r\n world\r\n0\r\n\r\n")
+	body, n, err := parseChunkedBody(buf)
+	if err != nil {
+		t.Fatalf("parseChunkedBody: %v", err)
+	}
+	if n != len(buf) {
+		t.Fatalf("consumed %d, want %d", n, len(buf))
+	}
+	if string(body) != "hello world" {
+		t.Fatalf("body = %q, want %q", body, "hello world")
+	}
+}
+
+func TestParseChunkedBodyNeedsMore(t *testing.T) {
+	cases := [][]byte{
+		[]byte("5\r\nhel"),
+		[]byte("5\r\nhello\r\n0\r\n"),
+	}
+	for _, buf := range cases {
+		if _, _, err := parseChunkedBody(buf); err != errNeedMore {
+			t.Fatalf("parseChunkedBody(%q) = %v, want errNeedMore", buf, err)
+		}
+	}
+}
+
+func TestParseRequestChunked(t *testing.T) {
+	buf := []byte("POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n4\r\nping\r\n0\r\n\r\n")
+	var ctx RequestCtx
+	n, err := parseRequest(buf, &ctx)
+	if err != nil {
+		t.Fatalf("parseRequest: %v", err)
+	}
+	if n != len(buf) {
+		t.Fatalf("consumed %d, want %d", n, len(buf))
+	}
+	if string(ctx.Body()) != "ping" {
+		t.Fatalf("body = %q, want %q", ctx.Body(), "ping")
+	}
+}
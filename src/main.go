@@ -7,6 +7,10 @@ import (
 	"runtime"
 )
 
+// bufSize is the size of the read buffers used across the server (4 KiB per
+// read, up from the original handleConn's 1 KiB buffer).
+const bufSize = 4096
+
 var rawResponse = []byte(
 	"HTTP/1.1 200 OK\r\n" +
 		"Content-Type: text/plain\r\n" +
@@ -15,23 +19,49 @@ var rawResponse = []byte(
 		"\r\n" +
 		"Hello, World!")
 
+// handler is invoked for every parsed request. It defaults to echoing
+// rawResponse regardless of Method/Path, matching the server's previous
+// behavior; callers can swap it out for real routing.
+var handler Handler = defaultHandler
+
+func defaultHandler(ctx *RequestCtx) []byte {
+	return rawResponse
+}
+
+// handleConn is the fallback goroutine-per-connection handler used on
+// platforms without an Engine (see serve_other.go). It parses requests off
+// conn with parseRequest, supporting keep-alive and pipelined requests, and
+// writes back whatever handler returns for each one.
 func handleConn(conn net.Conn) {
 
 	defer conn.Close()
 
-	buf := make([]byte, 1024)
+	var buf []byte
+	read := make([]byte, bufSize)
+	var ctx RequestCtx
 
 	for {
 
-		n, err := conn.Read(buf)
+		n, err := conn.Read(read)
 		if err != nil {
 			return
 		}
+		buf = append(buf, read[:n]...)
 
-		if n > 0 {
+		for {
+			consumed, perr := parseRequest(buf, &ctx)
+			if perr == errNeedMore {
+				break
+			}
+			if perr != nil {
+				return
+			}
 
-			_, err := conn.Write(rawResponse)
-			if err != nil {
+			if _, err := conn.Write(handler(&ctx)); err != nil {
+				return
+			}
+			buf = buf[consumed:]
+			if !ctx.keepAlive {
 				return
 			}
 		}
@@ -42,22 +72,23 @@ func main() {
 
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
-
-	ln, err := net.Listen("tcp", "0.0.0.0:8080")
-	if err != nil {
-		fmt.Printf("Listen failed: %v\n", err)
+	if err := initEngine(); err != nil {
+		fmt.Printf("engine init failed: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("🚀 Go Benchmark Server running on :8080 using %d cores\n", runtime.NumCPU())
-
-	for {
+	srv := &Server{Addr: "0.0.0.0:8080"}
 
-		conn, err := ln.Accept()
-		if err != nil {
-			continue
+	go func() {
+		if err := srv.ServeMetrics("0.0.0.0:8081"); err != nil {
+			fmt.Printf("metrics listener failed: %v\n", err)
 		}
+	}()
+
+	fmt.Printf("🚀 Go Benchmark Server running on :8080 using %d cores\n", runtime.NumCPU())
 
-		go handleConn(conn)
+	if err := srv.Serve(handleAccepted); err != nil {
+		fmt.Printf("serve failed: %v\n", err)
+		os.Exit(1)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// histogram is a fixed power-of-two-bucket latency histogram maintained
+// with plain atomic counters, good enough for rough p50/p99 reporting
+// without linking an HDR histogram library. Bucket i covers observations
+// up to 2^i microseconds, topping out around one second.
+type histogram struct {
+	buckets [21]int64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	us := d.Microseconds()
+	bucket := 0
+	for int64(1)<<uint(bucket) < us && bucket < len(h.buckets)-1 {
+		bucket++
+	}
+	atomic.AddInt64(&h.buckets[bucket], 1)
+}
+
+// percentile returns the smallest bucket boundary at or above the p-th
+// fraction of observations (p in [0,1]), e.g. percentile(0.99) for p99.
+func (h *histogram) percentile(p float64) time.Duration {
+	counts := make([]int64, len(h.buckets))
+	var total int64
+	for i := range h.buckets {
+		counts[i] = atomic.LoadInt64(&h.buckets[i])
+		total += counts[i]
+	}
+	if total == 0 {
+		return 0
+	}
+	target := p * float64(total)
+	var cum int64
+	for i, c := range counts {
+		cum += c
+		if float64(cum) >= target {
+			return time.Duration(int64(1)<<uint(i)) * time.Microsecond
+		}
+	}
+	return time.Duration(int64(1)<<uint(len(counts)-1)) * time.Microsecond
+}
+
+// Metrics collects the counters the /healthz, /readyz, and /metrics
+// endpoints report: everything the scheduler can track cheaply on its own,
+// without pulling in a Prometheus client library.
+type Metrics struct {
+	rings []*Ring[*Task]
+
+	activeConns  []int64 // per shard, atomic
+	steals       []int64 // per shard, atomic: successful worker TryPop count
+	acceptErrors int64   // atomic
+	latency      histogram
+
+	ready int32 // atomic; 1 once Serve is accepting, 0 again during Shutdown
+}
+
+// newMetrics returns a Metrics with shards independent shard counters. If
+// the caller dispatches through Rings, it can additionally set the rings
+// field directly to have /metrics report queue depth.
+func newMetrics(shards int) *Metrics {
+	return &Metrics{
+		activeConns: make([]int64, shards),
+		steals:      make([]int64, shards),
+	}
+}
+
+func (m *Metrics) connOpened(shard int)           { atomic.AddInt64(&m.activeConns[shard], 1) }
+func (m *Metrics) connClosed(shard int)           { atomic.AddInt64(&m.activeConns[shard], -1) }
+func (m *Metrics) acceptError()                   { atomic.AddInt64(&m.acceptErrors, 1) }
+func (m *Metrics) steal(shard int)                { atomic.AddInt64(&m.steals[shard], 1) }
+func (m *Metrics) observeLatency(d time.Duration) { m.latency.observe(d) }
+
+// setReady flips readiness; Shutdown calls setReady(false) first so load
+// balancers stop routing new connections here while in-flight ones drain.
+func (m *Metrics) setReady(ready bool) {
+	var v int32
+	if ready {
+		v = 1
+	}
+	atomic.StoreInt32(&m.ready, v)
+}
+
+func (m *Metrics) isReady() bool { return atomic.LoadInt32(&m.ready) == 1 }
+
+// serveMetrics runs a blocking HTTP listener on addr exposing /healthz,
+// /readyz, and /metrics. getMetrics is called on every request rather than
+// once, since ServeMetrics is typically started before Serve has populated
+// the Server's Metrics field; it may return nil until then, in which case
+// /readyz reports not-ready.
+func serveMetrics(addr string, getMetrics func() *Metrics) error {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		m := getMetrics()
+		if m == nil || !m.isReady() {
+			http.Error(w, "not ready\n", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ready\n"))
+	})
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, "goroutines %d\n", runtime.NumGoroutine())
+		m := getMetrics()
+		if m == nil {
+			return
+		}
+		fmt.Fprintf(w, "accept_errors %d\n", atomic.LoadInt64(&m.acceptErrors))
+		for i := range m.activeConns {
+			fmt.Fprintf(w, "shard%d_active_conns %d\n", i, atomic.LoadInt64(&m.activeConns[i]))
+			if i < len(m.rings) {
+				fmt.Fprintf(w, "shard%d_queue_depth %d\n", i, m.rings[i].Len())
+			}
+			fmt.Fprintf(w, "shard%d_steals %d\n", i, atomic.LoadInt64(&m.steals[i]))
+		}
+		fmt.Fprintf(w, "latency_p50_us %d\n", m.latency.percentile(0.50).Microseconds())
+		fmt.Fprintf(w, "latency_p99_us %d\n", m.latency.percentile(0.99).Microseconds())
+	})
+
+	return http.ListenAndServe(addr, mux)
+}
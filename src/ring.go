@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+// TaskOp identifies what a dispatched Task asks a worker to do with Conn.
+type TaskOp uint8
+
+const (
+	OpAccept TaskOp = iota
+	OpRead
+	OpWrite
+)
+
+// Task is one unit of work queued onto a Ring: a ready connection paired
+// with the op a worker should perform on it.
+type Task struct {
+	Conn net.Conn
+	Op   TaskOp
+}
+
+// slot is one ring-buffer cell. Its seq tracks how far ahead of the initial
+// index it has been claimed/published, which is how producers and
+// consumers agree on ownership without a shared lock.
+type slot[T any] struct {
+	seq  uint64
+	task T
+}
+
+// Ring is a lock-free bounded MPMC queue, Disruptor-style: every slot owns
+// a sequence counter, and producers/consumers CAS-claim a slot, do their
+// work, then publish by advancing that slot's sequence. It replaces a
+// channel as the hand-off between a producer goroutine (accepting
+// connections, or an epoll loop noticing readiness) and a pool of worker
+// goroutines. T is typically a pointer type; Ring's zero value for an
+// empty slot is T's zero value.
+type Ring[T any] struct {
+	mask  uint64
+	slots []slot[T]
+	head  uint64 // next slot index a producer will try to claim
+	tail  uint64 // next slot index a consumer will try to claim
+}
+
+// NewRing returns a Ring that can hold size Ts. size must be a power of two
+// so slot indices can be computed with a mask instead of a modulo.
+func NewRing[T any](size uint32) *Ring[T] {
+	if size == 0 || size&(size-1) != 0 {
+		panic("ring: size must be a power of two")
+	}
+	r := &Ring[T]{
+		mask:  uint64(size - 1),
+		slots: make([]slot[T], size),
+	}
+	for i := range r.slots {
+		r.slots[i].seq = uint64(i)
+	}
+	return r
+}
+
+// TryPush claims the next free slot and publishes task into it, returning
+// false without blocking if the ring is full.
+func (r *Ring[T]) TryPush(task T) bool {
+	for {
+		head := atomic.LoadUint64(&r.head)
+		s := &r.slots[head&r.mask]
+		seq := atomic.LoadUint64(&s.seq)
+		switch diff := int64(seq) - int64(head); {
+		case diff == 0:
+			if atomic.CompareAndSwapUint64(&r.head, head, head+1) {
+				s.task = task
+				atomic.StoreUint64(&s.seq, head+1)
+				return true
+			}
+		case diff < 0:
+			return false // ring is full
+		default:
+			// another producer already claimed this slot; retry
+		}
+	}
+}
+
+// TryPop claims the next published slot and returns its task, returning
+// false without blocking if the ring is empty.
+func (r *Ring[T]) TryPop() (T, bool) {
+	for {
+		tail := atomic.LoadUint64(&r.tail)
+		s := &r.slots[tail&r.mask]
+		seq := atomic.LoadUint64(&s.seq)
+		switch diff := int64(seq) - int64(tail+1); {
+		case diff == 0:
+			if atomic.CompareAndSwapUint64(&r.tail, tail, tail+1) {
+				task := s.task
+				var zero T
+				s.task = zero
+				atomic.StoreUint64(&s.seq, tail+uint64(len(r.slots)))
+				return task, true
+			}
+		case diff < 0:
+			var zero T
+			return zero, false // ring is empty
+		default:
+			// another consumer already claimed this slot; retry
+		}
+	}
+}
+
+// Len returns an approximate count of published tasks not yet popped. It
+// is meant for metrics reporting, not for synchronization: concurrent
+// pushes/pops can make it stale the instant it's read.
+func (r *Ring[T]) Len() int {
+	head := atomic.LoadUint64(&r.head)
+	tail := atomic.LoadUint64(&r.tail)
+	return int(head - tail)
+}
+
+// PopN drains up to len(dst) ready tasks into dst in one pass and returns
+// how many it popped, letting a consumer pull several ready items per wake
+// instead of calling TryPop one at a time.
+func (r *Ring[T]) PopN(dst []T) int {
+	n := 0
+	for n < len(dst) {
+		task, ok := r.TryPop()
+		if !ok {
+			break
+		}
+		dst[n] = task
+		n++
+	}
+	return n
+}
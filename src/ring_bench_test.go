@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+const ringBenchSize = 1 << 16
+
+// BenchmarkRingPushPop and BenchmarkChanPushPop compare the lock-free Ring
+// against the buffered chan *Task hand-off it replaces in runShard, single
+// producer/consumer.
+func BenchmarkRingPushPop(b *testing.B) {
+	r := NewRing[*Task](ringBenchSize)
+	task := &Task{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.TryPush(task)
+		r.TryPop()
+	}
+}
+
+func BenchmarkChanPushPop(b *testing.B) {
+	ch := make(chan *Task, ringBenchSize)
+	task := &Task{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ch <- task
+		<-ch
+	}
+}
+
+// BenchmarkRingConcurrent and BenchmarkChanConcurrent drive the same
+// comparison under GOMAXPROCS-wide contention, matching the write-heavy
+// multi-producer pattern the accept/worker hand-off sees in practice.
+func BenchmarkRingConcurrent(b *testing.B) {
+	r := NewRing[*Task](ringBenchSize)
+	task := &Task{}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			for !r.TryPush(task) {
+			}
+			for {
+				if _, ok := r.TryPop(); ok {
+					break
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkChanConcurrent(b *testing.B) {
+	ch := make(chan *Task, ringBenchSize)
+	task := &Task{}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			ch <- task
+			<-ch
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRingPushPopOrder(t *testing.T) {
+	r := NewRing[*Task](8)
+	tasks := make([]*Task, 4)
+	for i := range tasks {
+		tasks[i] = &Task{Op: TaskOp(i)}
+		if !r.TryPush(tasks[i]) {
+			t.Fatalf("TryPush %d failed", i)
+		}
+	}
+	for i, want := range tasks {
+		got, ok := r.TryPop()
+		if !ok {
+			t.Fatalf("TryPop %d: ring unexpectedly empty", i)
+		}
+		if got != want {
+			t.Fatalf("TryPop %d = %v, want %v", i, got, want)
+		}
+	}
+	if _, ok := r.TryPop(); ok {
+		t.Fatal("TryPop on empty ring succeeded")
+	}
+}
+
+func TestRingFull(t *testing.T) {
+	r := NewRing[*Task](4)
+	for i := 0; i < 4; i++ {
+		if !r.TryPush(&Task{}) {
+			t.Fatalf("TryPush %d failed before full", i)
+		}
+	}
+	if r.TryPush(&Task{}) {
+		t.Fatal("TryPush succeeded on full ring")
+	}
+	if _, ok := r.TryPop(); !ok {
+		t.Fatal("TryPop failed on non-empty ring")
+	}
+	if !r.TryPush(&Task{}) {
+		t.Fatal("TryPush failed after freeing a slot")
+	}
+}
+
+func TestRingPopN(t *testing.T) {
+	r := NewRing[*Task](8)
+	for i := 0; i < 5; i++ {
+		r.TryPush(&Task{})
+	}
+	dst := make([]*Task, 3)
+	if n := r.PopN(dst); n != 3 {
+		t.Fatalf("PopN = %d, want 3", n)
+	}
+	if r.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", r.Len())
+	}
+	if n := r.PopN(make([]*Task, 3)); n != 2 {
+		t.Fatalf("PopN = %d, want 2", n)
+	}
+	if n := r.PopN(make([]*Task, 3)); n != 0 {
+		t.Fatalf("PopN on empty ring = %d, want 0", n)
+	}
+}
+
+// TestRingConcurrent pushes and pops from many goroutines at once and
+// checks that exactly as many tasks are popped as were pushed, the MPMC
+// guarantee both the accept and engine dispatch paths depend on.
+func TestRingConcurrent(t *testing.T) {
+	const producers, perProducer, consumers = 8, 2000, 4
+	want := int64(producers * perProducer)
+
+	r := NewRing[*Task](1024)
+	var popped int64
+
+	var produce sync.WaitGroup
+	produce.Add(producers)
+	for i := 0; i < producers; i++ {
+		go func() {
+			defer produce.Done()
+			for j := 0; j < perProducer; j++ {
+				for !r.TryPush(&Task{}) {
+				}
+			}
+		}()
+	}
+
+	stop := make(chan struct{})
+	var consume sync.WaitGroup
+	consume.Add(consumers)
+	for i := 0; i < consumers; i++ {
+		go func() {
+			defer consume.Done()
+			for {
+				if _, ok := r.TryPop(); ok {
+					atomic.AddInt64(&popped, 1)
+					continue
+				}
+				select {
+				case <-stop:
+					return
+				default:
+				}
+			}
+		}()
+	}
+
+	produce.Wait()
+	for atomic.LoadInt64(&popped) < want {
+	}
+	close(stop)
+	consume.Wait()
+
+	if got := atomic.LoadInt64(&popped); got != want {
+		t.Fatalf("popped %d tasks, want %d", got, want)
+	}
+}
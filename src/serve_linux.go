@@ -0,0 +1,86 @@
+//go:build linux
+
+package main
+
+import "net"
+
+// engine is the shared proactor used to service every connection the
+// Server's shards accept.
+var engine *Engine
+
+// initEngine starts the shared Engine before Server.Serve begins accepting.
+func initEngine() error {
+	e, err := NewEngine()
+	if err != nil {
+		return err
+	}
+	engine = e
+	return nil
+}
+
+// handleAccepted registers conn with the shared Engine and hands it over to
+// the proactor instead of blocking the calling worker goroutine on it.
+func handleAccepted(conn net.Conn) {
+	fd, err := engine.Register(conn)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	c := &connState{conn: conn}
+	serveConn(engine, fd, c)
+}
+
+// connState accumulates bytes read from one connection across Engine
+// completions until a full request, or several pipelined ones, is
+// available to parseRequest.
+type connState struct {
+	conn net.Conn
+	buf  []byte
+	ctx  RequestCtx
+}
+
+// serveConn queues the next read for fd, parses whatever requests that
+// read completes, writes handler's response for each, and re-arms itself
+// until the connection is non-keep-alive or errors out.
+func serveConn(e *Engine, fd int, c *connState) {
+	e.Read(fd, nil, func(buf []byte, n int, err error) {
+		if err != nil || n == 0 {
+			closeConn(e, fd, c)
+			return
+		}
+		c.buf = append(c.buf, buf[:n]...)
+
+		for {
+			consumed, perr := parseRequest(c.buf, &c.ctx)
+			if perr == errNeedMore {
+				break
+			}
+			if perr != nil {
+				closeConn(e, fd, c)
+				return
+			}
+
+			resp := handler(&c.ctx)
+			c.buf = c.buf[consumed:]
+			keepAlive := c.ctx.keepAlive
+			e.Write(fd, resp, func(_ []byte, _ int, _ error) {
+				if !keepAlive {
+					closeConn(e, fd, c)
+				}
+			})
+			if !keepAlive {
+				return
+			}
+		}
+
+		serveConn(e, fd, c)
+	})
+}
+
+// closeConn unregisters fd from the engine before closing the underlying
+// conn, so the engine drops its fdState and epoll registration instead of
+// leaking them for the rest of the process's life.
+func closeConn(e *Engine, fd int, c *connState) {
+	e.Unregister(fd)
+	c.conn.Close()
+}
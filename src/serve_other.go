@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import "net"
+
+// initEngine is a no-op on platforms without an epoll-based Engine.
+func initEngine() error { return nil }
+
+// handleAccepted runs the original blocking per-connection loop; platforms
+// without an Engine service connections directly on the worker goroutine.
+func handleAccepted(conn net.Conn) {
+	handleConn(conn)
+}
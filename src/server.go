@@ -0,0 +1,285 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// workersPerShard is the number of handler goroutines draining each shard's
+// accept dispatch ring.
+const workersPerShard = 64
+
+// acceptQueueSize bounds how many accepted connections can sit in a
+// shard's dispatch ring before the accept loop spins waiting for room. It
+// must be a power of two (see NewRing).
+const acceptQueueSize = 1024
+
+// Server runs Shards independent listeners bound to the same Addr via
+// SO_REUSEPORT, one per CPU by default, each with its own accept goroutine
+// and a dedicated worker pool. This replaces a single net.Listen + single
+// accept loop, which becomes the bottleneck under C10K+ connection churn.
+type Server struct {
+	Addr   string
+	Shards int // listener/worker-pool count; defaults to runtime.NumCPU()
+
+	mu        sync.Mutex
+	listeners []net.Listener
+	wg        sync.WaitGroup
+	closed    int32
+
+	// connWG tracks connections handed to handler that are still open,
+	// separately from wg's worker goroutines: on the Linux engine path a
+	// worker goroutine returns from handler almost immediately, long
+	// before the connection it registered is actually done, so wg alone
+	// doesn't cover graceful drain.
+	connWG sync.WaitGroup
+
+	// queues holds each shard's accept dispatch queue so Shutdown can wake
+	// workers blocked waiting for the next connection.
+	queues []*shardQueue
+
+	// Metrics is populated once Serve starts and backs the /healthz,
+	// /readyz, and /metrics endpoints served by ServeMetrics.
+	Metrics *Metrics
+}
+
+// soReusePort is SO_REUSEPORT's value on Linux (syscall.SO_REUSEADDR's
+// sibling); the syscall package doesn't export it directly.
+const soReusePort = 0xf
+
+// reusePortControl sets SO_REUSEPORT on a shard's listening socket so every
+// shard can bind the same address and the kernel load-balances accepts
+// across them.
+func reusePortControl(_, _ string, c syscall.RawConn) error {
+	var ctlErr error
+	err := c.Control(func(fd uintptr) {
+		ctlErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return ctlErr
+}
+
+// Serve opens Shards SO_REUSEPORT listeners on Addr and dispatches accepted
+// connections to handler from a bounded worker pool per shard. It blocks
+// until every shard's workers have drained, which happens once Shutdown
+// closes the listeners.
+func (s *Server) Serve(handler func(net.Conn)) error {
+	shards := s.Shards
+	if shards <= 0 {
+		shards = runtime.NumCPU()
+	}
+
+	lc := net.ListenConfig{Control: reusePortControl}
+
+	s.mu.Lock()
+	for i := 0; i < shards; i++ {
+		ln, err := lc.Listen(context.Background(), "tcp", s.Addr)
+		if err != nil {
+			s.mu.Unlock()
+			return fmt.Errorf("shard %d listen: %w", i, err)
+		}
+		s.listeners = append(s.listeners, ln)
+	}
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	queues := make([]*shardQueue, len(listeners))
+	rings := make([]*Ring[*Task], len(listeners))
+	for i := range queues {
+		queues[i] = newShardQueue(acceptQueueSize)
+		rings[i] = queues[i].ring
+	}
+	s.queues = queues
+	s.Metrics = newMetrics(len(listeners))
+	s.Metrics.rings = rings
+
+	for i, ln := range listeners {
+		s.runShard(i, ln, queues[i], handler)
+	}
+
+	s.Metrics.setReady(true)
+	s.wg.Wait()
+	return nil
+}
+
+// shardQueue pairs a shard's accept dispatch Ring with a condition
+// variable so idle workers block instead of busy-spinning while waiting
+// for a connection, and wake as soon as one is accepted or Shutdown asks
+// them to stop.
+type shardQueue struct {
+	ring *Ring[*Task]
+	mu   sync.Mutex
+	cond *sync.Cond
+}
+
+func newShardQueue(size uint32) *shardQueue {
+	q := &shardQueue{ring: NewRing[*Task](size)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push publishes task, spinning only in the rare case the ring is
+// momentarily full, then wakes a worker blocked in pop.
+func (q *shardQueue) push(task *Task) {
+	for !q.ring.TryPush(task) {
+		runtime.Gosched()
+	}
+	q.cond.Broadcast()
+}
+
+// pop returns the next Task, blocking until one is available or stopped
+// reports true. The lock-free TryPop is tried first so the common,
+// non-empty case never pays for the mutex.
+func (q *shardQueue) pop(stopped func() bool) (*Task, bool) {
+	if task, ok := q.ring.TryPop(); ok {
+		return task, true
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if task, ok := q.ring.TryPop(); ok {
+			return task, true
+		}
+		if stopped() {
+			return nil, false
+		}
+		q.cond.Wait()
+	}
+}
+
+// runShard starts shard i's accept loop and its worker pool, dispatching
+// accepted connections through queue's lock-free Ring. Workers block in
+// queue.pop between connections instead of spinning, so an idle shard
+// costs nothing.
+func (s *Server) runShard(shard int, ln net.Listener, queue *shardQueue, handler func(net.Conn)) {
+	m := s.Metrics
+	stopped := func() bool { return atomic.LoadInt32(&s.closed) != 0 }
+
+	for i := 0; i < workersPerShard; i++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for {
+				task, ok := queue.pop(stopped)
+				if !ok {
+					return
+				}
+				m.steal(shard)
+				m.connOpened(shard)
+				s.connWG.Add(1)
+				start := time.Now()
+				mc := &meteredConn{Conn: task.Conn, onClose: func() {
+					m.observeLatency(time.Since(start))
+					m.connClosed(shard)
+					s.connWG.Done()
+				}}
+				handler(mc)
+			}
+		}()
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if atomic.LoadInt32(&s.closed) != 0 {
+					return
+				}
+				m.acceptError()
+				continue
+			}
+			queue.push(&Task{Conn: conn, Op: OpAccept})
+		}
+	}()
+}
+
+// meteredConn wraps an accepted net.Conn so the shard's active-connection
+// and latency metrics track its real lifetime instead of bracketing
+// handler's call: on the Linux engine path handler just registers the
+// conn and returns near-instantly, long before the connection's actual
+// work is done. onClose runs exactly once, whenever the connection is
+// actually closed, however that happens.
+type meteredConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+func (c *meteredConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.onClose)
+	return err
+}
+
+// SyscallConn passes through to the underlying conn's syscall.Conn.
+// Embedding net.Conn only promotes methods in the net.Conn interface, and
+// SyscallConn isn't one of them, so Engine.Register would otherwise fail
+// its type assertion on a wrapped conn.
+func (c *meteredConn) SyscallConn() (syscall.RawConn, error) {
+	return c.Conn.(syscall.Conn).SyscallConn()
+}
+
+// Shutdown stops accepting on every shard and waits for already-queued
+// connections to drain, or for ctx to expire first.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.Metrics != nil {
+		s.Metrics.setReady(false)
+	}
+	atomic.StoreInt32(&s.closed, 1)
+
+	s.mu.Lock()
+	for _, ln := range s.listeners {
+		ln.Close()
+	}
+	for _, q := range s.queues {
+		q.mu.Lock()
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		s.connWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ServeMetrics runs a side HTTP listener on addr exposing /healthz,
+// /readyz, and /metrics for this Server. It blocks, so callers typically
+// run it in its own goroutine alongside Serve.
+func (s *Server) ServeMetrics(addr string) error {
+	return serveMetrics(addr, func() *Metrics { return s.Metrics })
+}
+
+// BoundAddr returns the address shard 0 is actually listening on, useful
+// for tests that Serve on an Addr like "127.0.0.1:0" and need to dial back
+// in. It returns "" until Serve has opened its listeners.
+func (s *Server) BoundAddr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.listeners) == 0 {
+		return ""
+	}
+	return s.listeners[0].Addr().String()
+}
@@ -0,0 +1,105 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// Server is a single-listener fallback for platforms without SO_REUSEPORT
+// support in package syscall. Shards is accepted for API compatibility but
+// ignored.
+type Server struct {
+	Addr   string
+	Shards int
+
+	mu sync.Mutex
+	ln net.Listener
+	wg sync.WaitGroup
+
+	// Metrics is populated once Serve starts and backs the /healthz,
+	// /readyz, and /metrics endpoints served by ServeMetrics.
+	Metrics *Metrics
+}
+
+// Serve opens one listener on Addr and dispatches accepted connections to
+// handler, one goroutine per connection.
+func (s *Server) Serve(handler func(net.Conn)) error {
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+
+	s.Metrics = newMetrics(1)
+	s.Metrics.setReady(true)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.wg.Wait()
+			return nil
+		}
+		s.Metrics.connOpened(0)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			start := time.Now()
+			handler(conn)
+			s.Metrics.observeLatency(time.Since(start))
+			s.Metrics.connClosed(0)
+		}()
+	}
+}
+
+// Shutdown stops accepting and waits for in-flight connections to finish,
+// or for ctx to expire first.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.Metrics != nil {
+		s.Metrics.setReady(false)
+	}
+
+	s.mu.Lock()
+	ln := s.ln
+	s.mu.Unlock()
+	if ln != nil {
+		ln.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ServeMetrics runs a side HTTP listener on addr exposing /healthz,
+// /readyz, and /metrics for this Server. It blocks, so callers typically
+// run it in its own goroutine alongside Serve.
+func (s *Server) ServeMetrics(addr string) error {
+	return serveMetrics(addr, func() *Metrics { return s.Metrics })
+}
+
+// BoundAddr returns the address Serve is actually listening on, useful for
+// tests that Serve on an Addr like "127.0.0.1:0" and need to dial back in.
+// It returns "" until Serve has opened its listener.
+func (s *Server) BoundAddr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ln == nil {
+		return ""
+	}
+	return s.ln.Addr().String()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestServeEndToEnd drives a real Server through Serve, a client dial, and
+// Shutdown, checking the whole path (accept, parse, handle, write, close)
+// works end to end rather than just its pieces in isolation.
+func TestServeEndToEnd(t *testing.T) {
+	if err := initEngine(); err != nil {
+		t.Fatalf("initEngine: %v", err)
+	}
+
+	srv := &Server{Addr: "127.0.0.1:0", Shards: 1}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(handleAccepted) }()
+
+	var addr string
+	for i := 0; i < 200; i++ {
+		if addr = srv.BoundAddr(); addr != "" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if addr == "" {
+		t.Fatal("server never started listening")
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	req := "GET / HTTP/1.1\r\nHost: x\r\nConnection: close\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatalf("write request: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	got := make([]byte, len(rawResponse))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	if !bytes.Equal(got, rawResponse) {
+		t.Fatalf("response = %q, want %q", got, rawResponse)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if err := <-serveErr; err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+}